@@ -8,14 +8,15 @@ import (
 	"github.com/huandu/xstrings"
 	"github.com/masterminds/sprig"
 	"go/ast"
-	"go/format"
+	"go/doc"
 	"go/parser"
+	"go/printer"
 	"go/token"
 	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/imports"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 	"text/template"
@@ -24,6 +25,9 @@ import (
 //go:embed default.tpl
 var defaultTpl string
 
+//go:embed expectations.tpl
+var expectationsTpl string
+
 var ErrNoQuerier = errors.New("no Querier found")
 
 type Opts struct {
@@ -32,6 +36,7 @@ type Opts struct {
 	OutputFile    string
 	OutputPackage string
 	Format        bool
+	LocalPrefix   string
 }
 
 type Output struct {
@@ -52,6 +57,19 @@ type Method struct {
 	Name   string
 	Input  []Field
 	Output []Field
+	// Args and Rets mirror Input and Output but are guaranteed to have a
+	// Name (arg0, arg1, ... / ret0, ret1, ...) even when the Querier
+	// interface leaves its parameters or results unnamed, so the
+	// expectation machinery always has something to bind to.
+	Args []Field
+	Rets []Field
+	// SampleInputs and SampleOutputs are literal argument/return values
+	// mined from an ExampleQuerier_<Name> function in a sibling _test.go
+	// file, if one exists. Both are nil when no example was found;
+	// SampleOutputs is also nil when the example didn't yield a value for
+	// every return position (SampleInputs is still filled in then).
+	SampleInputs  []string
+	SampleOutputs []string
 }
 
 type Import struct {
@@ -59,61 +77,108 @@ type Import struct {
 	Name string
 }
 
+// inputDir returns the directory that should be parsed for opts.InputFile,
+// which may itself already be a directory (a package) or a single Go file.
+func inputDir(inputFile string) (string, error) {
+	fi, err := os.Stat(inputFile)
+	if err != nil {
+		return "", err
+	}
+	if fi.IsDir() {
+		return inputFile, nil
+	}
+	return filepath.Dir(inputFile), nil
+}
+
 func Parse(opts Opts) (Output, error) {
-	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, opts.InputFile, nil, parser.SkipObjectResolution)
+	dir, err := inputDir(opts.InputFile)
 	if err != nil {
-		log.Fatal(err)
+		return Output{}, err
 	}
 
-	var querier *ast.TypeSpec
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return Output{}, err
+	}
 
-	ast.Inspect(file, func(n ast.Node) bool {
-		tp, ok := n.(*ast.TypeSpec)
-		if ok && tp.Name.Name == "Querier" {
-			querier = tp
-			return false
+	var astPkg *ast.Package
+	for name, p := range pkgs {
+		if strings.HasSuffix(name, "_test") {
+			continue
 		}
-		return true
-	})
+		astPkg = p
+		break
+	}
+	if astPkg == nil {
+		return Output{}, ErrNoQuerier
+	}
+	pkg := astPkg.Name
+
+	// Every type declared anywhere in the package, so fields() can tell a
+	// local type (needs the "pkg." prefix in the generated mock, which
+	// lives outside the package) from one that's already qualified or
+	// builtin.
+	types := map[string]*ast.TypeSpec{}
+	for _, file := range astPkg.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			tp, ok := n.(*ast.TypeSpec)
+			if ok {
+				types[tp.Name.Name] = tp
+			}
+			return true
+		})
+	}
 
-	if querier == nil {
+	querier, ok := types["Querier"]
+	if !ok {
 		return Output{}, ErrNoQuerier
 	}
 
-	var methods []Method
-	pkg := file.Name.Name
-	var funcName string
-	ast.Inspect(querier, func(n ast.Node) bool {
-		ident, ok := n.(*ast.Ident)
-		if ok {
-			funcName = ident.Name
-		}
-		fn, ok := n.(*ast.FuncType)
-		if ok {
-			methods = append(methods, Method{
-				Name:   funcName,
-				Input:  fields(fn.Params, pkg),
-				Output: fields(fn.Results, pkg),
-			})
+	methods := collectMethods(fset, querier, types, pkg, map[string]bool{})
+
+	// Example functions are conventionally written in an external
+	// "foo_test" package, which go/parser.ParseDir puts in its own
+	// *ast.Package keyed by that name rather than astPkg - so gather
+	// _test.go files from every package ParseDir found, not just astPkg.
+	var testFiles []*ast.File
+	for _, p := range pkgs {
+		for name, file := range p.Files {
+			if strings.HasSuffix(name, "_test.go") {
+				testFiles = append(testFiles, file)
+			}
 		}
-		return true
-	})
+	}
+	seedSamples(fset, testFiles, types, pkg, methods)
 
 	imports := []Import{}
-	for _, imp := range file.Imports {
-		path, err := strconv.Unquote(imp.Path.Value)
-		if err != nil {
-			return Output{}, err
+	seenImports := map[string]bool{}
+	for fname, file := range astPkg.Files {
+		// Imports of _test.go files are not carried over to the generated
+		// mock: only the production files' imports are collected here (the
+		// examples in _test.go files were already mined by seedSamples), and
+		// a path imported by several of them is listed once.
+		if strings.HasSuffix(fname, "_test.go") {
+			continue
 		}
-		var name string
-		if imp.Name != nil {
-			name = imp.Name.Name
+		for _, imp := range file.Imports {
+			path, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				return Output{}, err
+			}
+			if seenImports[path] {
+				continue
+			}
+			seenImports[path] = true
+			var name string
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			imports = append(imports, Import{
+				Path: path,
+				Name: name,
+			})
 		}
-		imports = append(imports, Import{
-			Path: path,
-			Name: name,
-		})
 	}
 
 	output := Output{
@@ -130,52 +195,287 @@ func Parse(opts Opts) (Output, error) {
 	return output, nil
 }
 
-var needsPackageRe = regexp.MustCompile(`^([^A-Za-z0-9]*)([A-Z][^.]*)$`)
+// collectMethods walks an interface type's method set, following embedded
+// interfaces declared elsewhere in the package (e.g. a generated Querier
+// that embeds a hand-written base interface). seen guards against
+// embedding cycles.
+func collectMethods(fset *token.FileSet, iface *ast.TypeSpec, types map[string]*ast.TypeSpec, pkg string, seen map[string]bool) []Method {
+	if seen[iface.Name.Name] {
+		return nil
+	}
+	seen[iface.Name.Name] = true
+
+	it, ok := iface.Type.(*ast.InterfaceType)
+	if !ok {
+		return nil
+	}
+
+	var methods []Method
+	for _, field := range it.Methods.List {
+		if len(field.Names) == 0 {
+			// Embedded interface: field.Type names another type in the
+			// package whose methods belong to this interface too.
+			embedded, ok := types[exprToString(fset, field.Type)]
+			if ok {
+				methods = append(methods, collectMethods(fset, embedded, types, pkg, seen)...)
+			}
+			continue
+		}
+
+		fn, ok := field.Type.(*ast.FuncType)
+		if !ok {
+			continue
+		}
+		input := fields(fset, fn.Params, types, pkg)
+		output := fields(fset, fn.Results, types, pkg)
+		// Args and Rets are declared in the same Go scope in the generated
+		// mock (the method's parameter list and its "var (retN ...)"
+		// fallback block), so they need one shared namespace, not two
+		// independent ones.
+		seen := map[string]bool{}
+		methods = append(methods, Method{
+			Name:   field.Names[0].Name,
+			Input:  input,
+			Output: output,
+			Args:   withNames(input, "arg", seen),
+			Rets:   withNames(output, "ret", seen),
+		})
+	}
+	return methods
+}
+
+// reservedIdents are the identifiers the expectation templates declare in
+// the same scope as a method's Args and Rets (the receiver, and locals
+// introduced in the Expect/method-impl function bodies). A Querier parameter
+// that happens to reuse one of these would otherwise produce a "redeclared
+// in this block" compile error in the generated mock.
+var reservedIdents = map[string]bool{
+	"m": true, "c": true, "args": true, "ret": true, "fn": true,
+}
+
+// withNames returns a copy of fields where any Name that is blank, reserved
+// or already in seen is replaced with prefix+N, N being the first number from
+// the field's index up that isn't in seen, e.g. "arg0", "arg1". seen tracks
+// names already claimed across both the Args and Rets lists of the same
+// method, since they end up declared in the same Go scope in the generated
+// mock.
+func withNames(fields []Field, prefix string, seen map[string]bool) []Field {
+	ret := make([]Field, len(fields))
+	for i, f := range fields {
+		if f.Name == "" || reservedIdents[f.Name] || seen[f.Name] {
+			for n := i; ; n++ {
+				candidate := fmt.Sprintf("%s%d", prefix, n)
+				if !seen[candidate] {
+					f.Name = candidate
+					break
+				}
+			}
+		}
+		seen[f.Name] = true
+		ret[i] = f
+	}
+	return ret
+}
+
+// seedSamples looks for an ExampleQuerier_<Name> function per method among
+// testFiles and, when one exists, fills in SampleInputs and SampleOutputs
+// from the literal values it uses. Methods with no matching example are left
+// with nil samples; a method whose example doesn't yield a value for every
+// return position keeps its SampleInputs but gets nil SampleOutputs.
+func seedSamples(fset *token.FileSet, testFiles []*ast.File, types map[string]*ast.TypeSpec, pkg string, methods []Method) {
+	if len(testFiles) == 0 {
+		return
+	}
+
+	examples := map[string]*doc.Example{}
+	for _, ex := range doc.Examples(testFiles...) {
+		if name, ok := strings.CutPrefix(ex.Name, "Querier_"); ok {
+			examples[name] = ex
+		}
+	}
+
+	for i := range methods {
+		ex, ok := examples[methods[i].Name]
+		if !ok {
+			continue
+		}
+		methods[i].SampleInputs, methods[i].SampleOutputs = sampleFromExample(fset, methods[i], ex, types, pkg)
+	}
+}
+
+// sampleFromExample extracts the arguments passed to m in calls that form
+// the right-hand side of an assignment at the top level of ex, and, if ex
+// builds a composite literal of every one of m's non-error return types, the
+// literals to use as that method's default return values (error results
+// default to nil). The types of those return-value composite literals are
+// run through qualify() just like fields() does, since an ExampleQuerier_Xxx
+// necessarily names its types the way the source package sees them, not the
+// way the generated mock package will; the arguments are kept as written.
+func sampleFromExample(fset *token.FileSet, m Method, ex *doc.Example, types map[string]*ast.TypeSpec, pkg string) (inputs, outputs []string) {
+	block, ok := ex.Code.(*ast.BlockStmt)
+	if !ok {
+		return nil, nil
+	}
+
+	for _, stmt := range block.List {
+		assign, ok := stmt.(*ast.AssignStmt)
+		if !ok {
+			continue
+		}
+		for _, rhs := range assign.Rhs {
+			call, ok := rhs.(*ast.CallExpr)
+			if !ok {
+				continue
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != m.Name {
+				continue
+			}
+			for _, arg := range call.Args {
+				inputs = append(inputs, exprToString(fset, arg))
+			}
+		}
+	}
+
+	found := make([]string, len(m.Output))
+	for i, o := range m.Output {
+		if baseTypeName(o.Type) == "error" {
+			found[i] = "nil"
+		}
+	}
+	ast.Inspect(block, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+		name := compositeTypeName(lit.Type)
+		for i, o := range m.Output {
+			if found[i] == "" && name != "" && baseTypeName(o.Type) == name {
+				qualified := &ast.CompositeLit{Type: qualify(lit.Type, types, pkg), Elts: lit.Elts}
+				found[i] = exprToString(fset, qualified)
+				break
+			}
+		}
+		return true
+	})
+	for _, v := range found {
+		if v == "" {
+			return inputs, nil
+		}
+	}
+	return inputs, found
+}
+
+// baseTypeName strips pointer/slice/package qualification from a rendered
+// field type, e.g. "[]*models.GetUserRow" -> "GetUserRow".
+func baseTypeName(tp string) string {
+	tp = strings.TrimPrefix(tp, "[]")
+	tp = strings.TrimPrefix(tp, "*")
+	if idx := strings.LastIndex(tp, "."); idx >= 0 {
+		tp = tp[idx+1:]
+	}
+	return tp
+}
 
-func fields(list *ast.FieldList, pkg string) []Field {
+func compositeTypeName(expr ast.Expr) string {
+	switch v := expr.(type) {
+	case *ast.Ident:
+		return v.Name
+	case *ast.SelectorExpr:
+		return v.Sel.Name
+	default:
+		return ""
+	}
+}
+
+func fields(fset *token.FileSet, list *ast.FieldList, types map[string]*ast.TypeSpec, pkg string) []Field {
 	ret := []Field{}
 	for _, field := range list.List {
 		var name string
 		if len(field.Names) > 0 && field.Names[0] != nil {
 			name = field.Names[0].Name
 		}
-		tp := exprToString(field.Type)
-		matches := needsPackageRe.FindStringSubmatch(tp)
-		if matches != nil {
-			tp = matches[1] + pkg + "." + matches[2]
-		}
-
 		ret = append(ret, Field{
 			Name: name,
-			Type: tp,
+			Type: exprToString(fset, qualify(field.Type, types, pkg)),
 		})
 	}
 	return ret
 }
 
-// This is not general purpose; it's just for our use case.
-func exprToString(expr ast.Expr) string {
+// qualify returns a copy of expr with every identifier that names a type
+// declared in the source package rewritten to pkg.Ident, so the type still
+// resolves once the generated mock lives in a different package.
+// Identifiers belonging to another package (already a SelectorExpr) are
+// left untouched.
+func qualify(expr ast.Expr, types map[string]*ast.TypeSpec, pkg string) ast.Expr {
 	switch v := expr.(type) {
 	case *ast.Ident:
-		return v.Name
-	case *ast.SelectorExpr:
-		return v.X.(*ast.Ident).Name + "." + v.Sel.Name
-	case *ast.ArrayType:
-		return "[]" + exprToString(v.Elt)
+		if _, ok := types[v.Name]; !ok {
+			return v
+		}
+		return &ast.SelectorExpr{X: ast.NewIdent(pkg), Sel: ast.NewIdent(v.Name)}
 	case *ast.StarExpr:
-		return "*" + exprToString(v.X)
+		return &ast.StarExpr{X: qualify(v.X, types, pkg)}
+	case *ast.ArrayType:
+		return &ast.ArrayType{Len: v.Len, Elt: qualify(v.Elt, types, pkg)}
+	case *ast.Ellipsis:
+		return &ast.Ellipsis{Elt: qualify(v.Elt, types, pkg)}
+	case *ast.MapType:
+		return &ast.MapType{Key: qualify(v.Key, types, pkg), Value: qualify(v.Value, types, pkg)}
+	case *ast.ChanType:
+		return &ast.ChanType{Dir: v.Dir, Value: qualify(v.Value, types, pkg)}
+	case *ast.ParenExpr:
+		return &ast.ParenExpr{X: qualify(v.X, types, pkg)}
+	case *ast.FuncType:
+		return &ast.FuncType{Params: qualifyFieldList(v.Params, types, pkg), Results: qualifyFieldList(v.Results, types, pkg)}
+	case *ast.IndexExpr:
+		return &ast.IndexExpr{X: qualify(v.X, types, pkg), Index: qualify(v.Index, types, pkg)}
+	case *ast.IndexListExpr:
+		indices := make([]ast.Expr, len(v.Indices))
+		for i, idx := range v.Indices {
+			indices[i] = qualify(idx, types, pkg)
+		}
+		return &ast.IndexListExpr{X: qualify(v.X, types, pkg), Indices: indices}
 	default:
-		log.Printf("unhandled type %T\n", expr)
+		// *ast.SelectorExpr (already package-qualified), *ast.InterfaceType
+		// (interface{}/any) and anything else we don't need to rewrite.
+		return expr
+	}
+}
+
+func qualifyFieldList(list *ast.FieldList, types map[string]*ast.TypeSpec, pkg string) *ast.FieldList {
+	if list == nil {
+		return nil
+	}
+	newList := &ast.FieldList{}
+	for _, f := range list.List {
+		newList.List = append(newList.List, &ast.Field{Names: f.Names, Type: qualify(f.Type, types, pkg)})
+	}
+	return newList
+}
+
+// exprToString prints expr exactly as Go source, using printer.Fprint
+// against the file set it was parsed with so formatting (spacing, nested
+// generics, channel direction arrows, ...) matches the original.
+func exprToString(fset *token.FileSet, expr ast.Expr) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, expr); err != nil {
+		log.Printf("unhandled type %T: %v\n", expr, err)
 		return fmt.Sprintf("<error_unhandled_%T>", expr)
 	}
+	return buf.String()
 }
 
 func (output *Output) findOutputModule(opts Opts) error {
-	abs, err := filepath.Abs(opts.InputFile)
+	dir, err := inputDir(opts.InputFile)
+	if err != nil {
+		return err
+	}
+	dir, err = filepath.Abs(dir)
 	if err != nil {
 		return err
 	}
-	dir := filepath.Dir(abs)
 	root := findModuleRoot(dir)
 	modFile := filepath.Join(root, "go.mod")
 	modContent, err := os.ReadFile(modFile)
@@ -235,7 +535,15 @@ func (output *Output) Render(opts Opts) ([]byte, error) {
 	funcs := sprig.HermeticTxtFuncMap()
 	funcs["lcfirst"] = xstrings.FirstRuneToLower
 	funcs["ucfirst"] = xstrings.FirstRuneToUpper
-	tpl, err := template.New("mock").Funcs(funcs).Parse(templateContent)
+	tpl, err := template.New("mock").Funcs(funcs).Parse(expectationsTpl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Parsing the body content into the same *template.Template keeps the
+	// expectation machinery (CallLog, ExpectXxx, AssertExpectations, ...)
+	// available to --template overrides via {{template "expectationFields" .}}
+	// and friends, while still letting the override replace everything else.
+	tpl, err = tpl.Parse(templateContent)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -248,7 +556,15 @@ func (output *Output) Render(opts Opts) ([]byte, error) {
 		return buff.Bytes(), nil
 	}
 
-	result, err := format.Source(buff.Bytes())
+	// imports.Options has no LocalPrefix field; the x/tools/imports API
+	// exposes it as a package-level var instead.
+	imports.LocalPrefix = opts.LocalPrefix
+	result, err := imports.Process(opts.OutputFile, buff.Bytes(), &imports.Options{
+		Comments:   true,
+		TabIndent:  true,
+		TabWidth:   8,
+		FormatOnly: false,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to format source: %w", err)
 	}
@@ -0,0 +1,231 @@
+package generator
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQualify(t *testing.T) {
+	fset := token.NewFileSet()
+	types := map[string]*ast.TypeSpec{"Row": {}}
+
+	cases := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"bare ident", "Row", "pkg.Row"},
+		{"pointer", "*Row", "*pkg.Row"},
+		{"slice", "[]Row", "[]pkg.Row"},
+		{"map", "map[string]Row", "map[string]pkg.Row"},
+		{"already qualified", "other.Row", "other.Row"},
+		{"builtin untouched", "string", "string"},
+		{"send-receive chan", "chan Row", "chan pkg.Row"},
+		{"receive-only chan", "<-chan Row", "<-chan pkg.Row"},
+		{"func type", "func(Row) (Row, error)", "func(pkg.Row) (pkg.Row, error)"},
+		{"generic instantiation", "pgtype.Array[Row]", "pgtype.Array[pkg.Row]"},
+		{"interface untouched", "interface{}", "interface{}"},
+		{"paren", "(Row)", "(pkg.Row)"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(c.expr)
+			if err != nil {
+				t.Fatalf("ParseExpr(%q): %v", c.expr, err)
+			}
+			got := exprToString(fset, qualify(expr, types, "pkg"))
+			if got != c.want {
+				t.Errorf("qualify(%q) = %q, want %q", c.expr, got, c.want)
+			}
+		})
+	}
+}
+
+func TestExprToStringVariadic(t *testing.T) {
+	fset := token.NewFileSet()
+	fn, err := parser.ParseFile(fset, "fields.go", "package p\nfunc f(xs ...int) {}", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decl := fn.Decls[0].(*ast.FuncDecl)
+	param := decl.Type.Params.List[0]
+	got := exprToString(fset, param.Type)
+	if got != "...int" {
+		t.Errorf("exprToString(variadic) = %q, want %q", got, "...int")
+	}
+}
+
+func parseExampleFixture(t *testing.T) Output {
+	t.Helper()
+	out, err := Parse(Opts{InputFile: "testdata/examplepkg"})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return out
+}
+
+func methodByName(t *testing.T, out Output, name string) Method {
+	t.Helper()
+	for _, m := range out.Methods {
+		if m.Name == name {
+			return m
+		}
+	}
+	t.Fatalf("no method named %q in %v", name, out.Methods)
+	return Method{}
+}
+
+func TestSeedSamplesQualifiesCompositeLiterals(t *testing.T) {
+	out := parseExampleFixture(t)
+	getUser := methodByName(t, out, "GetUser")
+
+	if len(getUser.SampleOutputs) != 2 {
+		t.Fatalf("SampleOutputs = %v, want 2 values", getUser.SampleOutputs)
+	}
+	// The example refers to GetUserRow unqualified (it's an in-package
+	// test); the mock lives outside the package, so the sample must come
+	// out package-qualified or the generated DefaultGetUser() won't compile.
+	if !strings.Contains(getUser.SampleOutputs[0], "examplepkg.GetUserRow") {
+		t.Errorf("SampleOutputs[0] = %q, want it qualified with examplepkg.", getUser.SampleOutputs[0])
+	}
+	if getUser.SampleOutputs[1] != "nil" {
+		t.Errorf("SampleOutputs[1] = %q, want %q", getUser.SampleOutputs[1], "nil")
+	}
+}
+
+func TestSeedSamplesScansExternalTestPackage(t *testing.T) {
+	out := parseExampleFixture(t)
+	deleteUser := methodByName(t, out, "DeleteUser")
+
+	// ExampleQuerier_DeleteUser only exists in the external
+	// "examplepkg_test" package; if Parse only looked at astPkg's own
+	// _test.go files (the package-under-test's *ast.Package), this example
+	// would never be found and the samples would stay nil.
+	if len(deleteUser.SampleInputs) != 2 {
+		t.Fatalf("SampleInputs = %v, want 2 values mined from the external test package", deleteUser.SampleInputs)
+	}
+	if len(deleteUser.SampleOutputs) != 1 || deleteUser.SampleOutputs[0] != "nil" {
+		t.Errorf("SampleOutputs = %v, want [\"nil\"]", deleteUser.SampleOutputs)
+	}
+}
+
+func TestWithNamesAvoidsReservedIdentifiers(t *testing.T) {
+	out := parseExampleFixture(t)
+	doStuff := methodByName(t, out, "DoStuff")
+
+	for _, a := range doStuff.Args {
+		if reservedIdents[a.Name] {
+			t.Errorf("Args contains reserved identifier %q: %v", a.Name, doStuff.Args)
+		}
+	}
+}
+
+func TestWithNamesSharesNamespaceBetweenArgsAndRets(t *testing.T) {
+	out := parseExampleFixture(t)
+	countRows := methodByName(t, out, "CountRows")
+
+	// CountRows' second parameter is literally named "ret0", the name Rets
+	// would otherwise independently synthesize for its own unnamed first
+	// return value. Args and Rets share one Go scope in the generated mock,
+	// so they must share one naming namespace too.
+	seen := map[string]bool{}
+	for _, a := range countRows.Args {
+		if seen[a.Name] {
+			t.Errorf("Args/Rets share name %q: args=%v rets=%v", a.Name, countRows.Args, countRows.Rets)
+		}
+		seen[a.Name] = true
+	}
+	for _, r := range countRows.Rets {
+		if seen[r.Name] {
+			t.Errorf("Args/Rets share name %q: args=%v rets=%v", r.Name, countRows.Args, countRows.Rets)
+		}
+		seen[r.Name] = true
+	}
+}
+
+func TestParseDedupesImports(t *testing.T) {
+	out := parseExampleFixture(t)
+
+	// querier.go and querier_internal_test.go both import "context"; Parse
+	// must not emit it twice, or the generated mock fails with
+	// "context redeclared in this block".
+	seen := map[string]bool{}
+	for _, imp := range out.Imports {
+		if seen[imp.Path] {
+			t.Errorf("Imports contains duplicate path %q: %v", imp.Path, out.Imports)
+		}
+		seen[imp.Path] = true
+	}
+}
+
+// TestRenderedMockCompiles renders the default template against the
+// testdata fixture and actually builds the result, the way a maintainer
+// reviewing this generator would. It's skipped if the go toolchain isn't
+// on PATH.
+func TestRenderedMockCompiles(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	src := "testdata/examplepkg"
+	tmp := t.TempDir()
+	if err := copyDir(src, tmp); err != nil {
+		t.Fatalf("copyDir: %v", err)
+	}
+
+	opts := Opts{
+		InputFile:  tmp,
+		OutputFile: filepath.Join(tmp, "mocks", "mocker_gen.go"),
+		Format:     true,
+	}
+	output, err := Parse(opts)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	generated, err := output.Render(opts)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.OutputFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(opts.OutputFile, generated, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("go", "build", "./...")
+	cmd.Dir = tmp
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("generated mock does not compile: %v\n%s\n--- source ---\n%s", err, out, generated)
+	}
+}
+
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, content, 0o644)
+	})
+}
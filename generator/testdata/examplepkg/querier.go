@@ -0,0 +1,19 @@
+package examplepkg
+
+import "context"
+
+type GetUserRow struct {
+	ID   int
+	Name string
+}
+
+type Querier interface {
+	GetUser(ctx context.Context, id int) (GetUserRow, error)
+	DeleteUser(ctx context.Context, id int) error
+	// DoStuff exists to exercise a parameter name ("m") that collides
+	// with the generated mock's receiver.
+	DoStuff(ctx context.Context, m map[string]any, cb func(int) error) error
+	// CountRows exists to exercise a parameter name ("ret0") that collides
+	// with the auto-generated name for an unnamed first return value.
+	CountRows(ctx context.Context, ret0 int) (int, error)
+}
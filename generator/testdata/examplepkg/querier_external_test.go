@@ -0,0 +1,17 @@
+package examplepkg_test
+
+import (
+	"context"
+
+	"example.com/examplepkg"
+)
+
+// ExampleQuerier_DeleteUser lives in the external "examplepkg_test"
+// package, the conventional way to write Example functions - it exercises
+// that Parse scans that package's files too, not just examplepkg's own.
+func ExampleQuerier_DeleteUser() {
+	var q examplepkg.Querier
+	ctx := context.Background()
+	err := q.DeleteUser(ctx, 7)
+	_ = err
+}
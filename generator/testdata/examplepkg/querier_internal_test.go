@@ -0,0 +1,15 @@
+package examplepkg
+
+import "context"
+
+// ExampleQuerier_GetUser deliberately refers to GetUserRow unqualified, the
+// way an in-package example naturally would - this is the shape that
+// exercises the sample-qualification path.
+func ExampleQuerier_GetUser() {
+	var q Querier
+	ctx := context.Background()
+	want := GetUserRow{ID: 42, Name: "alice"}
+	row, _ := q.GetUser(ctx, 42)
+	_ = row
+	_ = want
+}
@@ -24,9 +24,10 @@ func run() error {
 	flag.StringVar(&opts.OutputFile, "output", "", "Output File")
 	flag.StringVar(&opts.OutputPackage, "package", "", "Output Package")
 	flag.BoolVar(&opts.Format, "format", true, "Format output")
+	flag.StringVar(&opts.LocalPrefix, "local-prefix", "", "Put imports beginning with this string after 3rd-party packages (passed to goimports)")
 	flag.Parse()
 	if flag.NArg() == 0 {
-		return errors.New("usage: shmock path/to/querier.go")
+		return errors.New("usage: shmock path/to/querier.go or path/to/package")
 	}
 
 	opts.InputFile = flag.Arg(0)